@@ -3,8 +3,10 @@ package consulcatalog
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -27,8 +29,26 @@ import (
 const (
 	// DefaultTemplateRule The default template for the default rule.
 	DefaultTemplateRule = "Host(`{{ normalize .Name }}`)"
+
+	// watchDebounce is the window used to coalesce bursts of watch events
+	// (e.g. many services changing at once) into a single configuration reload.
+	watchDebounce = 500 * time.Millisecond
+
+	// watchFallbackInterval is the polling interval used when watch mode is
+	// requested (RefreshInterval == 0) but the watch plans cannot be established.
+	watchFallbackInterval = 15 * time.Second
+
+	// defaultServiceCheckInterval and defaultServiceCheckTimeout are applied to
+	// an active (HTTP or TCP) ServiceCheck that doesn't set its own Interval or
+	// Timeout, matching the defaults documented on ServiceCheck.
+	defaultServiceCheckInterval = 10 * time.Second
+	defaultServiceCheckTimeout  = 5 * time.Second
 )
 
+// tagConstraintRe extracts the tag operand of simple `Tag(`foo`)` terms from a
+// constraints expression, so they can be pushed down as a Consul filter.
+var tagConstraintRe = regexp.MustCompile("Tag\\(`([^`]+)`\\)")
+
 var _ provider.Provider = (*Provider)(nil)
 
 type itemData struct {
@@ -37,34 +57,74 @@ type itemData struct {
 	Datacenter     string
 	Name           string
 	Namespace      string
+	Partition      string
 	Address        string
 	Port           string
 	Status         string
 	Labels         map[string]string
 	Tags           []string
+	NodeMeta       map[string]string
+	Peer           string
 	ConnectEnabled bool
 	ExtraConf      configuration
 }
 
 // Provider holds configurations of the provider.
 type Provider struct {
-	Constraints       string          `description:"Constraints is an expression that Traefik matches against the container's labels to determine whether to create any route for that container." json:"constraints,omitempty" toml:"constraints,omitempty" yaml:"constraints,omitempty" export:"true"`
-	Endpoint          *EndpointConfig `description:"Consul endpoint settings" json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty" export:"true"`
-	Prefix            string          `description:"Prefix for consul service tags. Default 'traefik'" json:"prefix,omitempty" toml:"prefix,omitempty" yaml:"prefix,omitempty" export:"true"`
-	RefreshInterval   ptypes.Duration `description:"Interval for check Consul API. Default 15s" json:"refreshInterval,omitempty" toml:"refreshInterval,omitempty" yaml:"refreshInterval,omitempty" export:"true"`
-	RequireConsistent bool            `description:"Forces the read to be fully consistent." json:"requireConsistent,omitempty" toml:"requireConsistent,omitempty" yaml:"requireConsistent,omitempty" export:"true"`
-	Stale             bool            `description:"Use stale consistency for catalog reads." json:"stale,omitempty" toml:"stale,omitempty" yaml:"stale,omitempty" export:"true"`
-	Cache             bool            `description:"Use local agent caching for catalog reads." json:"cache,omitempty" toml:"cache,omitempty" yaml:"cache,omitempty" export:"true"`
-	ExposedByDefault  bool            `description:"Expose containers by default." json:"exposedByDefault,omitempty" toml:"exposedByDefault,omitempty" yaml:"exposedByDefault,omitempty" export:"true"`
-	DefaultRule       string          `description:"Default rule." json:"defaultRule,omitempty" toml:"defaultRule,omitempty" yaml:"defaultRule,omitempty"`
-	ConnectAware      bool            `description:"Enable Consul Connect support." json:"connectAware,omitempty" toml:"connectAware,omitempty" yaml:"connectAware,omitempty"`
-	ConnectByDefault  bool            `description:"Automatically connect to a service via Consul connect." json:"connectByDefault,omitempty" toml:"connectByDefault,omitempty" yaml:"connectByDefault,omitempty"`
-	ServiceName       string          `description:"Name of the traefik service in Consul Catalog." json:"serviceName,omitempty" toml:"serviceName,omitempty" yaml:"serviceName,omitempty"`
-	ServicePort       int             `description:"Port of the traefik service to register in Consul Catalog" json:"servicePort,omitempty" toml:"servicePort,omitempty" yaml:"servicePort,omitempty"`
-
-	client         *api.Client
+	Constraints            string            `description:"Constraints is an expression that Traefik matches against the container's labels to determine whether to create any route for that container." json:"constraints,omitempty" toml:"constraints,omitempty" yaml:"constraints,omitempty" export:"true"`
+	Endpoint               *EndpointConfig   `description:"Consul endpoint settings" json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty" export:"true"` // Deprecated: use Endpoints instead.
+	Endpoints              []*EndpointConfig `description:"Consul endpoints settings, one per admin partition, namespace or cluster to discover services from." json:"endpoints,omitempty" toml:"endpoints,omitempty" yaml:"endpoints,omitempty" export:"true"`
+	Prefix                 string            `description:"Prefix for consul service tags. Default 'traefik'" json:"prefix,omitempty" toml:"prefix,omitempty" yaml:"prefix,omitempty" export:"true"`
+	RefreshInterval        ptypes.Duration   `description:"Interval for check Consul API. Default 15s. If set to 0, Traefik watches the catalog for changes instead of polling it." json:"refreshInterval,omitempty" toml:"refreshInterval,omitempty" yaml:"refreshInterval,omitempty" export:"true"`
+	RequireConsistent      bool              `description:"Forces the read to be fully consistent." json:"requireConsistent,omitempty" toml:"requireConsistent,omitempty" yaml:"requireConsistent,omitempty" export:"true"`
+	Stale                  bool              `description:"Use stale consistency for catalog reads." json:"stale,omitempty" toml:"stale,omitempty" yaml:"stale,omitempty" export:"true"`
+	Cache                  bool              `description:"Use local agent caching for catalog reads." json:"cache,omitempty" toml:"cache,omitempty" yaml:"cache,omitempty" export:"true"`
+	ExposedByDefault       bool              `description:"Expose containers by default." json:"exposedByDefault,omitempty" toml:"exposedByDefault,omitempty" yaml:"exposedByDefault,omitempty" export:"true"`
+	DefaultRule            string            `description:"Default rule." json:"defaultRule,omitempty" toml:"defaultRule,omitempty" yaml:"defaultRule,omitempty"`
+	ConnectAware           bool              `description:"Enable Consul Connect support." json:"connectAware,omitempty" toml:"connectAware,omitempty" yaml:"connectAware,omitempty"`
+	ConnectByDefault       bool              `description:"Automatically connect to a service via Consul connect." json:"connectByDefault,omitempty" toml:"connectByDefault,omitempty" yaml:"connectByDefault,omitempty"`
+	ServiceName            string            `description:"Name of the traefik service in Consul Catalog." json:"serviceName,omitempty" toml:"serviceName,omitempty" yaml:"serviceName,omitempty"`
+	ServicePort            int               `description:"Port of the traefik service to register in Consul Catalog" json:"servicePort,omitempty" toml:"servicePort,omitempty" yaml:"servicePort,omitempty"`
+	ServiceAddress         string            `description:"Address to advertise for the traefik service registered in Consul Catalog. If empty, Consul uses the registering agent's address." json:"serviceAddress,omitempty" toml:"serviceAddress,omitempty" yaml:"serviceAddress,omitempty"`
+	ServiceTags            []string          `description:"Tags to attach to the traefik service registered in Consul Catalog." json:"serviceTags,omitempty" toml:"serviceTags,omitempty" yaml:"serviceTags,omitempty"`
+	ServiceMeta            map[string]string `description:"Metadata key/value pairs to attach to the traefik service registered in Consul Catalog." json:"serviceMeta,omitempty" toml:"serviceMeta,omitempty" yaml:"serviceMeta,omitempty"`
+	ServiceChecks          []*ServiceCheck   `description:"Health checks to register along with the traefik service in Consul Catalog." json:"serviceChecks,omitempty" toml:"serviceChecks,omitempty" yaml:"serviceChecks,omitempty"`
+	ServiceDeregisterAfter ptypes.Duration   `description:"Grace period to wait before deregistering the traefik service from Consul Catalog on shutdown, to let in-flight requests drain." json:"serviceDeregisterAfter,omitempty" toml:"serviceDeregisterAfter,omitempty" yaml:"serviceDeregisterAfter,omitempty"`
+	FilterExpression       string            `description:"Raw Consul filter expression, ANDed with the filter generated from Prefix and Constraints." json:"filterExpression,omitempty" toml:"filterExpression,omitempty" yaml:"filterExpression,omitempty" export:"true"`
+	NodeMeta               map[string]string `description:"Node metadata key/value pairs to filter nodes for a given service." json:"nodeMeta,omitempty" toml:"nodeMeta,omitempty" yaml:"nodeMeta,omitempty" export:"true"`
+	Peers                  []string          `description:"Names of the peered Consul clusters to discover services from, in addition to the local cluster." json:"peers,omitempty" toml:"peers,omitempty" yaml:"peers,omitempty" export:"true"`
+
 	defaultRuleTpl *template.Template
 	certChan       chan *connectCert
+	endpoints      []*endpointState
+}
+
+// endpointState holds the runtime state associated with one configured Consul
+// endpoint (e.g. a distinct admin partition, namespace, or peered cluster).
+type endpointState struct {
+	config *EndpointConfig
+	client *api.Client
+
+	// servicesIndexes and serviceIndexes hold the last known Consul modify
+	// indexes, keyed by peer, so that watch-mode blocking queries only return
+	// once the catalog has actually changed. serviceIndexes is further keyed
+	// by "peer/service".
+	servicesIndexes sync.Map // map[string]uint64
+	serviceIndexes  sync.Map // map[string]uint64
+}
+
+// watchMode reports whether the provider should use Consul blocking queries
+// and watch plans to react to catalog changes instead of polling on
+// RefreshInterval.
+func (p *Provider) watchMode() bool {
+	return p.RefreshInterval == 0
+}
+
+// peers returns the list of Consul clusters to query: the local cluster
+// (denoted by the empty string, i.e. api.QueryOptions.Peer left unset) plus
+// any configured peered clusters.
+func (p *Provider) peers() []string {
+	return append([]string{""}, p.Peers...)
 }
 
 // EndpointConfig holds configurations of the endpoint.
@@ -76,6 +136,8 @@ type EndpointConfig struct {
 	TLS              *types.ClientTLS        `description:"Enable TLS support." json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
 	HTTPAuth         *EndpointHTTPAuthConfig `description:"Auth info to use for http access" json:"httpAuth,omitempty" toml:"httpAuth,omitempty" yaml:"httpAuth,omitempty" export:"true"`
 	EndpointWaitTime ptypes.Duration         `description:"WaitTime limits how long a Watch will block. If not provided, the agent default values will be used" json:"endpointWaitTime,omitempty" toml:"endpointWaitTime,omitempty" yaml:"endpointWaitTime,omitempty" export:"true"`
+	Namespace        string                  `description:"Consul Enterprise namespace to query. If not provided, the default namespace is used" json:"namespace,omitempty" toml:"namespace,omitempty" yaml:"namespace,omitempty" export:"true"`
+	Partition        string                  `description:"Consul Enterprise admin partition to query. If not provided, the default partition is used" json:"partition,omitempty" toml:"partition,omitempty" yaml:"partition,omitempty" export:"true"`
 }
 
 // SetDefaults sets the default values.
@@ -89,11 +151,20 @@ type EndpointHTTPAuthConfig struct {
 	Password string `description:"Basic Auth password" json:"password,omitempty" toml:"password,omitempty" yaml:"password,omitempty"`
 }
 
+// ServiceCheck holds the configuration of a Consul health check registered
+// along with the traefik Connect Native service. Exactly one of HTTP, TCP or
+// TTL is expected to be set.
+type ServiceCheck struct {
+	HTTP                           string          `description:"URL to probe, e.g. traefik's ping endpoint." json:"http,omitempty" toml:"http,omitempty" yaml:"http,omitempty"`
+	TCP                            string          `description:"TCP address to probe, e.g. traefik's Connect Native service port." json:"tcp,omitempty" toml:"tcp,omitempty" yaml:"tcp,omitempty"`
+	TTL                            ptypes.Duration `description:"Have Consul expect a TTL ping from traefik at this interval, instead of actively probing it." json:"ttl,omitempty" toml:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Interval                       ptypes.Duration `description:"Interval between two consecutive HTTP or TCP checks. Default 10s." json:"interval,omitempty" toml:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout                        ptypes.Duration `description:"Timeout of a HTTP or TCP check. Default 5s." json:"timeout,omitempty" toml:"timeout,omitempty" yaml:"timeout,omitempty"`
+	DeregisterCriticalServiceAfter ptypes.Duration `description:"Automatically deregister the service if this check stays critical for longer than this duration." json:"deregisterCriticalServiceAfter,omitempty" toml:"deregisterCriticalServiceAfter,omitempty" yaml:"deregisterCriticalServiceAfter,omitempty"`
+}
+
 // SetDefaults sets the default values.
 func (p *Provider) SetDefaults() {
-	endpoint := &EndpointConfig{}
-	endpoint.SetDefaults()
-	p.Endpoint = endpoint
 	p.RefreshInterval = ptypes.Duration(15 * time.Second)
 	p.Prefix = "traefik"
 	p.ExposedByDefault = true
@@ -105,7 +176,34 @@ func (p *Provider) SetDefaults() {
 
 // Init the provider.
 func (p *Provider) Init() error {
-	defaultRuleTpl, err := provider.MakeDefaultRuleTemplate(p.DefaultRule, nil)
+	endpoints := p.Endpoints
+	if p.Endpoint != nil {
+		log.WithoutContext().Warn("consulCatalog: endpoint is deprecated, please use endpoints instead")
+		endpoints = append(endpoints, p.Endpoint)
+	}
+
+	if len(endpoints) == 0 {
+		endpoint := &EndpointConfig{}
+		endpoint.SetDefaults()
+		endpoints = []*EndpointConfig{endpoint}
+	}
+
+	for _, cfg := range endpoints {
+		client, err := createClient(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to create consul client: %w", err)
+		}
+
+		p.endpoints = append(p.endpoints, &endpointState{config: cfg, client: client})
+	}
+
+	funcMap := template.FuncMap{
+		"nodeMeta": func(meta map[string]string, key string) string {
+			return meta[key]
+		},
+	}
+
+	defaultRuleTpl, err := provider.MakeDefaultRuleTemplate(p.DefaultRule, funcMap)
 	if err != nil {
 		return fmt.Errorf("error while parsing default rule: %w", err)
 	}
@@ -114,166 +212,263 @@ func (p *Provider) Init() error {
 	return nil
 }
 
+// primaryEndpoint returns the endpoint used to register Traefik itself as a
+// Connect Native service and to watch its Connect certificates. Traefik
+// registers itself against the first configured endpoint only.
+func (p *Provider) primaryEndpoint() *endpointState {
+	return p.endpoints[0]
+}
+
 // Provide allows the consul catalog provider to provide configurations to traefik using the given configuration channel.
+// One loader goroutine is run per configured endpoint (admin partition, namespace or peered cluster); their results
+// are merged and published as a single dynamic.Message per update.
 func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
 	if p.ConnectAware {
 		pool.GoCtx(p.registerConnectService)
 		pool.GoCtx(p.watchConnectTLS)
 	}
 
-	pool.GoCtx(func(routineCtx context.Context) {
-		ctxLog := log.With(routineCtx, log.Str(log.ProviderName, "consulcatalog"))
-		logger := log.FromContext(ctxLog)
+	var mu sync.Mutex
+	dataset := make([][]itemData, len(p.endpoints))
 
-		operation := func() error {
-			var (
-				err      error
-				certInfo *connectCert
-			)
+	publish := make(chan struct{}, 1)
+	notifyPublish := func() {
+		select {
+		case publish <- struct{}{}:
+		default:
+		}
+	}
 
-			p.client, err = createClient(p.Endpoint)
-			if err != nil {
-				return fmt.Errorf("unable to create consul client: %w", err)
-			}
+	for i, ep := range p.endpoints {
+		i, ep := i, ep
+		pool.GoCtx(func(routineCtx context.Context) {
+			ctxLog := log.With(routineCtx, log.Str(log.ProviderName, "consulcatalog"), log.Str("consulEndpoint", ep.config.Address))
+			logger := log.FromContext(ctxLog)
+
+			// refresh fetches the current catalog data and publishes it. blocking
+			// controls whether the underlying Consul queries are allowed to
+			// long-poll on the last-known index: that's only appropriate for the
+			// ticker-driven fallback loop below, which has no other way to learn
+			// about a change. A refresh triggered by a watch.Plan (the normal
+			// case in watch mode) already knows a change happened, so it must
+			// read immediately instead of blocking again on the very same index.
+			refresh := func(ctx context.Context, blocking bool) error {
+				data, err := p.getConsulServicesData(ctx, ep, blocking)
+				if err != nil {
+					return err
+				}
 
-			// If we are running in connect aware mode then we need to
-			// make sure that we obtain the certificates before starting
-			// the service watcher, otherwise a connect enabled service
-			// that gets resolved before the certificates are available
-			// will cause an error condition.
-			if p.ConnectAware {
-				certInfo = <-p.certChan
-			}
+				mu.Lock()
+				dataset[i] = data
+				mu.Unlock()
+				notifyPublish()
 
-			// get configuration at the provider's startup.
-			err = p.loadConfiguration(routineCtx, certInfo, configurationChan)
-			if err != nil {
-				return fmt.Errorf("failed to get consul catalog data: %w", err)
+				return nil
 			}
 
-			// Periodic refreshes.
-			ticker := time.NewTicker(time.Duration(p.RefreshInterval))
-			defer ticker.Stop()
+			operation := func() error {
+				// get configuration at the provider's startup.
+				if err := refresh(routineCtx, false); err != nil {
+					return fmt.Errorf("failed to get consul catalog data: %w", err)
+				}
+
+				if p.watchMode() {
+					if err := p.watchConsulCatalog(routineCtx, ep, refresh); err != nil {
+						logger.Errorf("Watch-based updates failed, falling back to polling: %v", err)
+					} else {
+						return nil
+					}
+				}
 
-			for {
-				select {
-				case <-ticker.C:
-					err = p.loadConfiguration(routineCtx, certInfo, configurationChan)
-					if err != nil {
-						return fmt.Errorf("failed to refresh consul catalog data: %w", err)
+				// Periodic refreshes, either because watch mode is disabled, or as a
+				// fallback when the watch plans above could not be established.
+				refreshInterval := time.Duration(p.RefreshInterval)
+				if refreshInterval <= 0 {
+					refreshInterval = watchFallbackInterval
+				}
+				ticker := time.NewTicker(refreshInterval)
+				defer ticker.Stop()
+
+				for {
+					select {
+					case <-ticker.C:
+						if err := refresh(routineCtx, p.watchMode()); err != nil {
+							return fmt.Errorf("failed to refresh consul catalog data: %w", err)
+						}
+					case <-routineCtx.Done():
+						return nil
 					}
-				case certInfo = <-p.certChan:
-					// nothing much to do, next ticker cycle will propagate
-					// the updates.
-				case <-routineCtx.Done():
-					return nil
 				}
 			}
-		}
 
-		notify := func(err error, time time.Duration) {
-			logger.Errorf("Provider connection error %+v, retrying in %s", err, time)
+			notify := func(err error, time time.Duration) {
+				logger.Errorf("Provider connection error %+v, retrying in %s", err, time)
+			}
+
+			err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(job.NewBackOff(backoff.NewExponentialBackOff()), ctxLog), notify)
+			if err != nil {
+				logger.Errorf("Cannot connect to consul catalog server %+v", err)
+			}
+		})
+	}
+
+	pool.GoCtx(func(routineCtx context.Context) {
+		ctxLog := log.With(routineCtx, log.Str(log.ProviderName, "consulcatalog"))
+
+		// If we are running in connect aware mode then we need to make sure
+		// that we obtain the certificates before publishing any configuration,
+		// otherwise a connect enabled service that gets resolved before the
+		// certificates are available will cause an error condition.
+		var certInfo *connectCert
+		if p.ConnectAware {
+			certInfo = <-p.certChan
 		}
 
-		err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(job.NewBackOff(backoff.NewExponentialBackOff()), ctxLog), notify)
-		if err != nil {
-			logger.Errorf("Cannot connect to consul catalog server %+v", err)
+		for {
+			select {
+			case <-publish:
+				mu.Lock()
+				var merged []itemData
+				for _, data := range dataset {
+					merged = append(merged, data...)
+				}
+				mu.Unlock()
+
+				configurationChan <- dynamic.Message{
+					ProviderName:  "consulcatalog",
+					Configuration: p.buildConfiguration(ctxLog, merged, certInfo),
+				}
+
+			case certInfo = <-p.certChan:
+				notifyPublish()
+
+			case <-routineCtx.Done():
+				return
+			}
 		}
 	})
 
 	return nil
 }
 
-func (p *Provider) loadConfiguration(ctx context.Context, certInfo *connectCert, configurationChan chan<- dynamic.Message) error {
-	data, err := p.getConsulServicesData(ctx)
-	if err != nil {
-		return err
+// qualifiedServiceName suffixes a Consul service name with its non-default
+// namespace, admin partition, and peer, so that two tenants exposing a
+// same-named service from different namespaces/partitions/peers don't collide
+// once item.Name is used as the base for router/service names in the
+// generated configuration.
+func qualifiedServiceName(name, namespace, partition, peer string) string {
+	if namespace != "" && namespace != "default" {
+		name += "-" + namespace
 	}
-
-	configurationChan <- dynamic.Message{
-		ProviderName:  "consulcatalog",
-		Configuration: p.buildConfiguration(ctx, data, certInfo),
+	if partition != "" && partition != "default" {
+		name += "-" + partition
 	}
-
-	return nil
+	if peer != "" {
+		name += "-" + peer
+	}
+	return name
 }
 
-func (p *Provider) getConsulServicesData(ctx context.Context) ([]itemData, error) {
-	consulServiceNames, err := p.fetchServices(ctx)
+func (p *Provider) getConsulServicesData(ctx context.Context, ep *endpointState, blocking bool) ([]itemData, error) {
+	consulServiceNames, err := p.fetchServices(ctx, ep, blocking)
 	if err != nil {
 		return nil, err
 	}
 
 	var data []itemData
-	for name, connectEnabled := range consulServiceNames {
-		consulServices, statuses, err := p.fetchService(ctx, name, connectEnabled)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, consulService := range consulServices {
-			address := consulService.ServiceAddress
-			if address == "" {
-				address = consulService.Address
-			}
-			namespace := consulService.Namespace
-			if namespace == "" {
-				namespace = "default"
+	for peer, names := range consulServiceNames {
+		for name, connectEnabled := range names {
+			consulServices, statuses, err := p.fetchService(ctx, ep, name, connectEnabled, peer, blocking)
+			if err != nil {
+				return nil, err
 			}
 
-			status, exists := statuses[consulService.ID+consulService.ServiceID]
-			if !exists {
-				status = api.HealthAny
-			}
+			for _, consulService := range consulServices {
+				address := consulService.ServiceAddress
+				if address == "" {
+					address = consulService.Address
+				}
+				namespace := consulService.Namespace
+				if namespace == "" {
+					namespace = ep.config.Namespace
+				}
+				if namespace == "" {
+					namespace = "default"
+				}
 
-			item := itemData{
-				ID:             consulService.ServiceID,
-				Node:           consulService.Node,
-				Datacenter:     consulService.Datacenter,
-				Namespace:      namespace,
-				Name:           name,
-				Address:        address,
-				Port:           strconv.Itoa(consulService.ServicePort),
-				Labels:         tagsToNeutralLabels(consulService.ServiceTags, p.Prefix),
-				Tags:           consulService.ServiceTags,
-				Status:         status,
-				ConnectEnabled: connectEnabled,
-			}
+				status, exists := statuses[consulService.ID+consulService.ServiceID]
+				if !exists {
+					status = api.HealthAny
+				}
 
-			extraConf, err := p.getConfiguration(item)
-			if err != nil {
-				log.FromContext(ctx).Errorf("Skip item %s: %v", item.Name, err)
-				continue
-			}
-			item.ExtraConf = extraConf
+				item := itemData{
+					ID:             consulService.ServiceID,
+					Node:           consulService.Node,
+					Datacenter:     consulService.Datacenter,
+					Namespace:      namespace,
+					Partition:      ep.config.Partition,
+					Name:           qualifiedServiceName(name, namespace, ep.config.Partition, peer),
+					Address:        address,
+					Port:           strconv.Itoa(consulService.ServicePort),
+					Labels:         tagsToNeutralLabels(consulService.ServiceTags, p.Prefix),
+					Tags:           consulService.ServiceTags,
+					NodeMeta:       consulService.NodeMeta,
+					Peer:           peer,
+					Status:         status,
+					ConnectEnabled: connectEnabled,
+				}
+
+				extraConf, err := p.getConfiguration(item)
+				if err != nil {
+					log.FromContext(ctx).Errorf("Skip item %s: %v", item.Name, err)
+					continue
+				}
+				item.ExtraConf = extraConf
 
-			data = append(data, item)
+				data = append(data, item)
+			}
 		}
 	}
 	return data, nil
 }
 
-func (p *Provider) fetchService(ctx context.Context, name string, connectEnabled bool) ([]*api.CatalogService, map[string]string, error) {
+func (p *Provider) fetchService(ctx context.Context, ep *endpointState, name string, connectEnabled bool, peer string, blocking bool) ([]*api.CatalogService, map[string]string, error) {
 	var tagFilter string
 	if !p.ExposedByDefault {
 		tagFilter = p.Prefix + ".enable=true"
 	}
 
-	opts := &api.QueryOptions{AllowStale: p.Stale, RequireConsistent: p.RequireConsistent, UseCache: p.Cache}
+	indexKey := peer + "/" + name
+
+	opts := &api.QueryOptions{
+		AllowStale: p.Stale, RequireConsistent: p.RequireConsistent, UseCache: p.Cache,
+		Filter: p.buildCatalogFilter(), NodeMeta: p.NodeMeta, Peer: peer,
+		Namespace: ep.config.Namespace, Partition: ep.config.Partition,
+	}
+	if blocking {
+		if idx, ok := ep.serviceIndexes.Load(indexKey); ok {
+			opts.WaitIndex = idx.(uint64)
+		}
+		opts.WaitTime = time.Duration(ep.config.EndpointWaitTime)
+	}
 	opts = opts.WithContext(ctx)
 
-	catalogFunc := p.client.Catalog().Service
-	healthFunc := p.client.Health().Service
+	catalogFunc := ep.client.Catalog().Service
+	healthFunc := ep.client.Health().Service
 	if connectEnabled {
-		catalogFunc = p.client.Catalog().Connect
-		healthFunc = p.client.Health().Connect
+		catalogFunc = ep.client.Catalog().Connect
+		healthFunc = ep.client.Health().Connect
 	}
 
-	consulServices, _, err := catalogFunc(name, tagFilter, opts)
+	consulServices, meta, err := catalogFunc(name, tagFilter, opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if blocking && meta != nil {
+		ep.serviceIndexes.Store(indexKey, meta.LastIndex)
+	}
+
 	healthServices, _, err := healthFunc(name, tagFilter, false, opts)
 	if err != nil {
 		return nil, nil, err
@@ -293,53 +488,78 @@ func (p *Provider) fetchService(ctx context.Context, name string, connectEnabled
 	return consulServices, statuses, err
 }
 
-func (p *Provider) fetchServices(ctx context.Context) (map[string]bool, error) {
-	// The query option "Filter" is not supported by /catalog/services.
-	// https://www.consul.io/api/catalog.html#list-services
-	opts := &api.QueryOptions{AllowStale: p.Stale, RequireConsistent: p.RequireConsistent, UseCache: p.Cache}
-	serviceNames, _, err := p.client.Catalog().Services(opts)
-	if err != nil {
-		return nil, err
-	}
-
-	filtered := make(map[string]bool)
-	// The keys are the service names, and the array values provide all known tags for a given service.
-	// https://www.consul.io/api/catalog.html#list-services
-	for svcName, tags := range serviceNames {
-		logger := log.FromContext(log.With(ctx, log.Str("serviceName", svcName)))
-
-		if !p.ExposedByDefault && !contains(tags, p.Prefix+".enable=true") {
-			logger.Debug("Filtering disabled item")
-			continue
+// fetchServices lists, per peer, the Consul services exposed to Traefik along
+// with whether each one is Connect-enabled. Tracking is kept per peer, rather
+// than flattened by service name, because two peers can expose a same-named
+// service with different connect tagging.
+func (p *Provider) fetchServices(ctx context.Context, ep *endpointState, blocking bool) (map[string]map[string]bool, error) {
+	filtered := make(map[string]map[string]bool)
+
+	for _, peer := range p.peers() {
+		// https://www.consul.io/api/catalog.html#list-services
+		opts := &api.QueryOptions{
+			AllowStale: p.Stale, RequireConsistent: p.RequireConsistent, UseCache: p.Cache,
+			Filter: p.buildCatalogFilter(), NodeMeta: p.NodeMeta, Peer: peer,
+			Namespace: ep.config.Namespace, Partition: ep.config.Partition,
 		}
-
-		if contains(tags, p.Prefix+".enable=false") {
-			logger.Debug("Filtering disabled item")
-			continue
+		if blocking {
+			if idx, ok := ep.servicesIndexes.Load(peer); ok {
+				opts.WaitIndex = idx.(uint64)
+			}
+			opts.WaitTime = time.Duration(ep.config.EndpointWaitTime)
 		}
+		opts = opts.WithContext(ctx)
 
-		matches, err := constraints.MatchTags(tags, p.Constraints)
+		serviceNames, meta, err := ep.client.Catalog().Services(opts)
 		if err != nil {
-			logger.Errorf("Error matching constraints expression: %v", err)
-			continue
+			return nil, err
 		}
 
-		if !matches {
-			logger.Debugf("Container pruned by constraint expression: %q", p.Constraints)
-			continue
+		if blocking && meta != nil {
+			ep.servicesIndexes.Store(peer, meta.LastIndex)
 		}
 
-		connect := p.ConnectByDefault
-		if contains(tags, p.Prefix+".connect=true") {
-			connect = true
-		} else if contains(tags, p.Prefix+".connect=false") {
-			connect = false
-		}
+		// The keys are the service names, and the array values provide all known tags for a given service.
+		// https://www.consul.io/api/catalog.html#list-services
+		for svcName, tags := range serviceNames {
+			logger := log.FromContext(log.With(ctx, log.Str("serviceName", svcName)))
+
+			if !p.ExposedByDefault && !contains(tags, p.Prefix+".enable=true") {
+				logger.Debug("Filtering disabled item")
+				continue
+			}
+
+			if contains(tags, p.Prefix+".enable=false") {
+				logger.Debug("Filtering disabled item")
+				continue
+			}
+
+			matches, err := constraints.MatchTags(tags, p.Constraints)
+			if err != nil {
+				logger.Errorf("Error matching constraints expression: %v", err)
+				continue
+			}
+
+			if !matches {
+				logger.Debugf("Container pruned by constraint expression: %q", p.Constraints)
+				continue
+			}
+
+			connect := p.ConnectByDefault
+			if contains(tags, p.Prefix+".connect=true") {
+				connect = true
+			} else if contains(tags, p.Prefix+".connect=false") {
+				connect = false
+			}
 
-		filtered[svcName] = connect
+			if filtered[peer] == nil {
+				filtered[peer] = make(map[string]bool)
+			}
+			filtered[peer][svcName] = connect
+		}
 	}
 
-	return filtered, err
+	return filtered, nil
 }
 
 func contains(values []string, val string) bool {
@@ -351,6 +571,193 @@ func contains(values []string, val string) bool {
 	return false
 }
 
+// buildCatalogFilter builds the Consul catalog filter expression used to push
+// the enable/disable tag rule and any constraint that can be expressed as a
+// required tag down to the Consul server, so that large catalogs only return
+// the services that are actually relevant. It is ANDed with FilterExpression
+// when one is configured. Client-side matching in fetchServices remains the
+// source of truth and still runs for expressions this cannot translate (e.g.
+// constraints combining terms with "||" or "!").
+func (p *Provider) buildCatalogFilter() string {
+	var exprs []string
+
+	if !p.ExposedByDefault {
+		exprs = append(exprs, fmt.Sprintf("ServiceTags contains %q", p.Prefix+".enable=true"))
+	}
+
+	for _, tag := range constraintTagsFromExpression(p.Constraints) {
+		exprs = append(exprs, fmt.Sprintf("ServiceTags contains %q", tag))
+	}
+
+	if p.FilterExpression != "" {
+		// Parenthesized so that a top-level "or"/"not" in the user-supplied
+		// expression can't change how it binds with the generated terms above,
+		// since Consul's filter grammar binds "and" tighter than "or".
+		exprs = append(exprs, "("+p.FilterExpression+")")
+	}
+
+	return strings.Join(exprs, " and ")
+}
+
+// constraintTagsFromExpression extracts the tags required by simple
+// `Tag(`foo`)` terms of a constraints expression. Terms using any other
+// constraint function are not extracted and are left to client-side matching.
+// Expressions containing "||" or "!" yield no tags at all.
+func constraintTagsFromExpression(expression string) []string {
+	if expression == "" || strings.Contains(expression, "||") || strings.Contains(expression, "!") {
+		return nil
+	}
+
+	var tags []string
+	for _, match := range tagConstraintRe.FindAllStringSubmatch(expression, -1) {
+		tags = append(tags, match[1])
+	}
+	return tags
+}
+
+// watchConsulCatalog reacts to catalog changes as they happen, instead of
+// polling on RefreshInterval. It runs a "services" watch plan for the
+// catalog-wide list of service names, and one "service" watch plan per
+// currently known service, starting and stopping them as services come and
+// go. Bursts of events are coalesced over watchDebounce before triggering a
+// single configuration reload. It returns an error, without blocking, if the
+// initial watch plans cannot be set up, so the caller can fall back to the
+// ticker-based loop.
+func (p *Provider) watchConsulCatalog(ctx context.Context, ep *endpointState, refresh func(context.Context, bool) error) error {
+	logger := log.FromContext(ctx)
+
+	reload := make(chan struct{}, 1)
+	notifyReload := func() {
+		select {
+		case reload <- struct{}{}:
+		default:
+		}
+	}
+
+	servicesPlan, err := watch.Parse(map[string]interface{}{"type": "services"})
+	if err != nil {
+		return fmt.Errorf("unable to create services watch plan: %w", err)
+	}
+	servicesPlan.HybridHandler = func(_ watch.BlockingParamVal, _ interface{}) {
+		notifyReload()
+	}
+
+	logOpts := &hclog.LoggerOptions{
+		Name:       "consulcatalog",
+		Level:      hclog.LevelFromString(logrus.GetLevel().String()),
+		JSONFormat: true,
+	}
+	hclogger := hclog.New(logOpts)
+
+	watchErrChan := make(chan error, 1)
+	go func() {
+		if err := servicesPlan.RunWithClientAndHclog(ep.client, hclogger); err != nil {
+			watchErrChan <- fmt.Errorf("services watch plan failed: %w", err)
+		}
+	}()
+	defer servicesPlan.Stop()
+
+	serviceWatches := make(map[string]*watch.Plan)
+	defer func() {
+		for _, plan := range serviceWatches {
+			plan.Stop()
+		}
+	}()
+
+	// deadPlans carries the names of service watch plans that have exited on
+	// their own (e.g. a transient connection error), so syncServiceWatches can
+	// reap them and let them be restarted instead of leaving a stale entry in
+	// serviceWatches that blocks it from ever being recreated. It's a plain
+	// mutex-guarded map, not a bounded channel, so a burst of failures wider
+	// than any fixed buffer still can't be silently dropped.
+	var deadMu sync.Mutex
+	deadPlans := make(map[string]bool)
+
+	syncServiceWatches := func() {
+		deadMu.Lock()
+		for name := range deadPlans {
+			delete(serviceWatches, name)
+		}
+		deadPlans = make(map[string]bool)
+		deadMu.Unlock()
+
+		byPeer, err := p.fetchServices(ctx, ep, false)
+		if err != nil {
+			logger.Errorf("failed to list services for watch synchronization: %v", err)
+			return
+		}
+
+		names := make(map[string]bool)
+		for _, peerNames := range byPeer {
+			for name := range peerNames {
+				names[name] = true
+			}
+		}
+
+		for name := range names {
+			if _, ok := serviceWatches[name]; ok {
+				continue
+			}
+
+			svcPlan, err := watch.Parse(map[string]interface{}{"type": "service", "service": name})
+			if err != nil {
+				logger.Errorf("unable to create service watch plan for %s: %v", name, err)
+				continue
+			}
+			svcPlan.HybridHandler = func(_ watch.BlockingParamVal, _ interface{}) {
+				notifyReload()
+			}
+
+			serviceWatches[name] = svcPlan
+			go func(name string, plan *watch.Plan) {
+				if err := plan.RunWithClientAndHclog(ep.client, hclogger); err != nil {
+					logger.Errorf("service watch plan for %s failed: %v", name, err)
+					deadMu.Lock()
+					deadPlans[name] = true
+					deadMu.Unlock()
+					notifyReload()
+				}
+			}(name, svcPlan)
+		}
+
+		for name, plan := range serviceWatches {
+			if _, ok := names[name]; !ok {
+				plan.Stop()
+				delete(serviceWatches, name)
+			}
+		}
+	}
+
+	syncServiceWatches()
+
+	debounce := time.NewTimer(watchDebounce)
+	if !debounce.Stop() {
+		<-debounce.C
+	}
+
+	for {
+		select {
+		case <-reload:
+			debounce.Reset(watchDebounce)
+
+		case <-debounce.C:
+			// Not blocking: a watch.Plan already told us something changed, so
+			// read the current state immediately instead of long-polling again
+			// on the very index that plan was itself waiting on.
+			if err := refresh(ctx, false); err != nil {
+				logger.Errorf("failed to refresh consul catalog data: %v", err)
+			}
+			syncServiceWatches()
+
+		case err := <-watchErrChan:
+			return err
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
 func (p *Provider) registerConnectService(ctx context.Context) {
 	if !p.ConnectAware {
 		return
@@ -363,26 +770,27 @@ func (p *Provider) registerConnectService(ctx context.Context) {
 		p.ServiceName = "traefik"
 	}
 
-	client, err := createClient(p.Endpoint)
-	if err != nil {
-		logger.WithError(err).Error("failed to create consul client")
-		return
-	}
+	client := p.primaryEndpoint().client
 
 	serviceID := uuid.New().String()
-	operation := func() error {
-		regReq := &api.AgentServiceRegistration{
-			ID:   serviceID,
-			Kind: api.ServiceKindTypical,
-			Name: p.ServiceName,
-			Port: p.ServicePort,
-			Connect: &api.AgentServiceConnect{
-				Native: true,
-			},
-		}
+	regReq := &api.AgentServiceRegistration{
+		ID:      serviceID,
+		Kind:    api.ServiceKindTypical,
+		Name:    p.ServiceName,
+		Port:    p.ServicePort,
+		Address: p.ServiceAddress,
+		Tags:    p.ServiceTags,
+		Meta:    p.ServiceMeta,
+		Connect: &api.AgentServiceConnect{
+			Native: true,
+		},
+		Checks: p.buildServiceChecks(serviceID),
+	}
 
-		err = client.Agent().ServiceRegister(regReq)
-		if err != nil {
+	ttlCheckIDs, ttlPingInterval := p.ttlCheckIDs(serviceID)
+
+	operation := func() error {
+		if err := client.Agent().ServiceRegister(regReq); err != nil {
 			return fmt.Errorf("failed to register service in consul catalog. %w", err)
 		}
 
@@ -393,19 +801,124 @@ func (p *Provider) registerConnectService(ctx context.Context) {
 		logger.Errorf("Failed to register traefik as Connect Native service in consul catalog. %s", err)
 	}
 
-	err = backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(job.NewBackOff(backoff.NewExponentialBackOff()), context.Background()), notify)
+	err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(job.NewBackOff(backoff.NewExponentialBackOff()), context.Background()), notify)
 	if err != nil {
 		logger.WithError(err).Error("failed to register traefik in consul catalog as connect native service")
 		return
 	}
 
-	<-ctx.Done()
-	err = client.Agent().ServiceDeregister(serviceID)
-	if err != nil {
+	// Consul agents can lose their local state (and thus this registration) on
+	// restart, so periodically re-assert it rather than registering only once.
+	reassertInterval := time.Duration(p.RefreshInterval)
+	if reassertInterval <= 0 {
+		reassertInterval = watchFallbackInterval
+	}
+	ticker := time.NewTicker(reassertInterval)
+	defer ticker.Stop()
+
+	// A TTL check otherwise never leaves the critical state, so it must be
+	// pinged well inside its TTL window rather than just on re-registration.
+	var ttlTickerC <-chan time.Time
+	if len(ttlCheckIDs) > 0 {
+		ttlTicker := time.NewTicker(ttlPingInterval)
+		defer ttlTicker.Stop()
+		ttlTickerC = ttlTicker.C
+	}
+
+drain:
+	for {
+		select {
+		case <-ticker.C:
+			if err := client.Agent().ServiceRegister(regReq); err != nil {
+				logger.WithError(err).Error("failed to re-assert traefik registration in consul catalog")
+			}
+		case <-ttlTickerC:
+			for _, checkID := range ttlCheckIDs {
+				if err := client.Agent().UpdateTTL(checkID, "", api.HealthPassing); err != nil {
+					logger.WithError(err).Errorf("failed to pass TTL check %s", checkID)
+				}
+			}
+		case <-ctx.Done():
+			break drain
+		}
+	}
+
+	if drainWindow := time.Duration(p.ServiceDeregisterAfter); drainWindow > 0 {
+		logger.Debugf("Draining for %s before deregistering traefik from consul catalog", drainWindow)
+		time.Sleep(drainWindow)
+	}
+
+	if err := client.Agent().ServiceDeregister(serviceID); err != nil {
 		logger.WithError(err).Error("failed to deregister traefik from consul catalog")
 	}
 }
 
+// buildServiceChecks translates the configured ServiceChecks into the Consul
+// agent API checks registered along with the traefik Connect Native service.
+// Each check is given an explicit CheckID, derived from serviceID, so that a
+// TTL check can later be looked up by ttlCheckIDs and pinged without depending
+// on Consul's own auto-generated IDs.
+func (p *Provider) buildServiceChecks(serviceID string) api.AgentServiceChecks {
+	var checks api.AgentServiceChecks
+	for i, check := range p.ServiceChecks {
+		interval := check.Interval
+		timeout := check.Timeout
+
+		// The Consul agent rejects an active (HTTP or TCP) check registered
+		// without an interval, so fall back to the documented defaults instead
+		// of silently registering a check that can never pass.
+		if check.HTTP != "" || check.TCP != "" {
+			if interval <= 0 {
+				interval = ptypes.Duration(defaultServiceCheckInterval)
+			}
+			if timeout <= 0 {
+				timeout = ptypes.Duration(defaultServiceCheckTimeout)
+			}
+		}
+
+		checks = append(checks, &api.AgentServiceCheck{
+			CheckID:                        fmt.Sprintf("%s:check:%d", serviceID, i),
+			HTTP:                           check.HTTP,
+			TCP:                            check.TCP,
+			TTL:                            durationString(check.TTL),
+			Interval:                       durationString(interval),
+			Timeout:                        durationString(timeout),
+			DeregisterCriticalServiceAfter: durationString(check.DeregisterCriticalServiceAfter),
+		})
+	}
+	return checks
+}
+
+// ttlCheckIDs returns the CheckID (as assigned by buildServiceChecks) and
+// ping interval of every configured TTL check, so registerConnectService can
+// keep them passing. A TTL check otherwise stays critical forever: Consul
+// expects an explicit passing update within the TTL window, which merely
+// re-registering the service does not provide.
+func (p *Provider) ttlCheckIDs(serviceID string) (ids []string, pingInterval time.Duration) {
+	for i, check := range p.ServiceChecks {
+		if check.TTL <= 0 {
+			continue
+		}
+
+		ids = append(ids, fmt.Sprintf("%s:check:%d", serviceID, i))
+
+		interval := time.Duration(check.TTL) / 2
+		if pingInterval == 0 || interval < pingInterval {
+			pingInterval = interval
+		}
+	}
+	return ids, pingInterval
+}
+
+// durationString renders a ptypes.Duration as the string format expected by
+// the Consul agent API (e.g. "10s"), leaving it empty when unset.
+func durationString(d ptypes.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return time.Duration(d).String()
+}
+
 func rootsWatchHandler(ctx context.Context, dest chan<- []string) func(watch.BlockingParamVal, interface{}) {
 	return func(_ watch.BlockingParamVal, raw interface{}) {
 		if raw == nil {
@@ -451,15 +964,49 @@ func leafWatcherHandler(ctx context.Context, dest chan<- keyPair) func(watch.Blo
 	}
 }
 
+// fetchPeerTrustBundles reads the exported CA roots of every configured
+// peered cluster, so that upstream endpoints discovered through a peering
+// connection can be validated against the peer's own trust bundle rather
+// than only the local cluster's roots.
+func (p *Provider) fetchPeerTrustBundles(ctx context.Context, client *api.Client) map[string][]string {
+	if len(p.Peers) == 0 {
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+
+	bundles := make(map[string][]string)
+	for _, peer := range p.Peers {
+		bundle, _, err := client.Peerings().ReadTrustBundle(peer, &api.QueryOptions{})
+		if err != nil {
+			logger.WithError(err).Errorf("failed to read trust bundle for peer %s", peer)
+			continue
+		}
+		if bundle == nil {
+			continue
+		}
+
+		bundles[peer] = bundle.RootPEMs
+	}
+
+	return bundles
+}
+
+// mergeRoots combines the local cluster's CA roots with those exported by
+// any peered cluster into a single trust bundle.
+func mergeRoots(localRoots []string, peerRoots map[string][]string) []string {
+	merged := append([]string{}, localRoots...)
+	for _, roots := range peerRoots {
+		merged = append(merged, roots...)
+	}
+	return merged
+}
+
 func (p *Provider) watchConnectTLS(ctx context.Context) {
 	ctxLog := log.With(ctx, log.Str(log.ProviderName, "consulcatalog"))
 	logger := log.FromContext(ctxLog)
 
-	client, err := createClient(p.Endpoint)
-	if err != nil {
-		logger.WithError(err).Errorf("failed to create consul client")
-		return
-	}
+	client := p.primaryEndpoint().client
 
 	leafWatcher, err := watch.Parse(map[string]interface{}{
 		"type":    "connect_leaf",
@@ -507,16 +1054,19 @@ func (p *Provider) watchConnectTLS(ctx context.Context) {
 
 	leafCerts := <-leafChan
 	rootCerts := <-rootChan
+	peerRoots := p.fetchPeerTrustBundles(ctxLog, client)
 
-	certInfo := &connectCert{
+	p.certChan <- &connectCert{
 		service: p.ServiceName,
-		root:    rootCerts,
+		root:    mergeRoots(rootCerts, peerRoots),
 		leaf:    leafCerts,
 	}
 
-	p.certChan <- certInfo
-
-	ticker := time.NewTicker(time.Duration(p.RefreshInterval))
+	reassertInterval := time.Duration(p.RefreshInterval)
+	if reassertInterval <= 0 {
+		reassertInterval = watchFallbackInterval
+	}
+	ticker := time.NewTicker(reassertInterval)
 
 	for {
 		select {
@@ -528,9 +1078,13 @@ func (p *Provider) watchConnectTLS(ctx context.Context) {
 		case leafCerts = <-leafChan:
 
 		case <-ticker.C:
+			// Peered clusters don't expose a blocking-query watch for their
+			// exported trust bundles, so they are re-fetched on the same
+			// cadence as the periodic re-assertion of the local roots/leaf.
+			peerRoots = p.fetchPeerTrustBundles(ctxLog, client)
 			p.certChan <- &connectCert{
 				service: p.ServiceName,
-				root:    rootCerts,
+				root:    mergeRoots(rootCerts, peerRoots),
 				leaf:    leafCerts,
 			}
 		}
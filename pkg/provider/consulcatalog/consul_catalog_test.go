@@ -0,0 +1,299 @@
+package consulcatalog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	ptypes "github.com/traefik/paerser/types"
+)
+
+func TestProvider_buildCatalogFilter(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		provider Provider
+		expected string
+	}{
+		{
+			desc:     "exposed by default, no constraint, no filter expression",
+			provider: Provider{ExposedByDefault: true, Prefix: "traefik"},
+			expected: "",
+		},
+		{
+			desc:     "not exposed by default",
+			provider: Provider{ExposedByDefault: false, Prefix: "traefik"},
+			expected: `ServiceTags contains "traefik.enable=true"`,
+		},
+		{
+			desc:     "simple tag constraint",
+			provider: Provider{ExposedByDefault: true, Prefix: "traefik", Constraints: "Tag(`foo`)"},
+			expected: `ServiceTags contains "foo"`,
+		},
+		{
+			desc:     "constraint with || is left to client-side matching",
+			provider: Provider{ExposedByDefault: true, Prefix: "traefik", Constraints: "Tag(`foo`) || Tag(`bar`)"},
+			expected: "",
+		},
+		{
+			desc:     "user-supplied filter expression is parenthesized",
+			provider: Provider{ExposedByDefault: true, Prefix: "traefik", FilterExpression: `ServiceTags contains "blue" or ServiceTags contains "green"`},
+			expected: `(ServiceTags contains "blue" or ServiceTags contains "green")`,
+		},
+		{
+			desc:     "filter expression ANDed with the enable tag clause",
+			provider: Provider{ExposedByDefault: false, Prefix: "traefik", FilterExpression: `ServiceTags contains "blue" or ServiceTags contains "green"`},
+			expected: `ServiceTags contains "traefik.enable=true" and (ServiceTags contains "blue" or ServiceTags contains "green")`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := test.provider.buildCatalogFilter()
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestConstraintTagsFromExpression(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		expression string
+		expected   []string
+	}{
+		{
+			desc:       "empty expression",
+			expression: "",
+			expected:   nil,
+		},
+		{
+			desc:       "single tag term",
+			expression: "Tag(`foo`)",
+			expected:   []string{"foo"},
+		},
+		{
+			desc:       "multiple tag terms",
+			expression: "Tag(`foo`) && Tag(`bar`)",
+			expected:   []string{"foo", "bar"},
+		},
+		{
+			desc:       "bails out on ||",
+			expression: "Tag(`foo`) || Tag(`bar`)",
+			expected:   nil,
+		},
+		{
+			desc:       "bails out on negation",
+			expression: "!Tag(`foo`)",
+			expected:   nil,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := constraintTagsFromExpression(test.expression)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestQualifiedServiceName(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		name      string
+		namespace string
+		partition string
+		peer      string
+		expected  string
+	}{
+		{
+			desc:     "default namespace and partition, no peer",
+			name:     "api",
+			expected: "api",
+		},
+		{
+			desc:      "non-default namespace",
+			name:      "api",
+			namespace: "billing",
+			expected:  "api-billing",
+		},
+		{
+			desc:      "non-default namespace and partition",
+			name:      "api",
+			namespace: "billing",
+			partition: "eng",
+			expected:  "api-billing-eng",
+		},
+		{
+			desc:     "peer suffix",
+			name:     "api",
+			peer:     "cluster-b",
+			expected: "api-cluster-b",
+		},
+		{
+			desc:      "namespace, partition and peer combined",
+			name:      "api",
+			namespace: "billing",
+			partition: "eng",
+			peer:      "cluster-b",
+			expected:  "api-billing-eng-cluster-b",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := qualifiedServiceName(test.name, test.namespace, test.partition, test.peer)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestMergeRoots(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		localRoots []string
+		peerRoots  map[string][]string
+		expected   []string
+	}{
+		{
+			desc:       "no peers",
+			localRoots: []string{"local-root"},
+			expected:   []string{"local-root"},
+		},
+		{
+			desc:       "local roots merged with every peer's roots",
+			localRoots: []string{"local-root"},
+			peerRoots: map[string][]string{
+				"cluster-b": {"peer-root-1"},
+			},
+			expected: []string{"local-root", "peer-root-1"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := mergeRoots(test.localRoots, test.peerRoots)
+			assert.ElementsMatch(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDurationString(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		duration ptypes.Duration
+		expected string
+	}{
+		{
+			desc:     "zero duration",
+			duration: 0,
+			expected: "",
+		},
+		{
+			desc:     "negative duration",
+			duration: ptypes.Duration(-time.Second),
+			expected: "",
+		},
+		{
+			desc:     "positive duration",
+			duration: ptypes.Duration(10 * time.Second),
+			expected: "10s",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := durationString(test.duration)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestProvider_buildServiceChecks(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		checks   []*ServiceCheck
+		expected api.AgentServiceChecks
+	}{
+		{
+			desc: "HTTP check without interval/timeout gets the documented defaults",
+			checks: []*ServiceCheck{
+				{HTTP: "http://127.0.0.1/ping"},
+			},
+			expected: api.AgentServiceChecks{
+				{
+					CheckID:  "svc:check:0",
+					HTTP:     "http://127.0.0.1/ping",
+					Interval: "10s",
+					Timeout:  "5s",
+				},
+			},
+		},
+		{
+			desc: "explicit interval/timeout are preserved",
+			checks: []*ServiceCheck{
+				{TCP: "127.0.0.1:8080", Interval: ptypes.Duration(30 * time.Second), Timeout: ptypes.Duration(2 * time.Second)},
+			},
+			expected: api.AgentServiceChecks{
+				{
+					CheckID:  "svc:check:0",
+					TCP:      "127.0.0.1:8080",
+					Interval: "30s",
+					Timeout:  "2s",
+				},
+			},
+		},
+		{
+			desc: "TTL check is left without an interval",
+			checks: []*ServiceCheck{
+				{TTL: ptypes.Duration(15 * time.Second)},
+			},
+			expected: api.AgentServiceChecks{
+				{
+					CheckID: "svc:check:0",
+					TTL:     "15s",
+				},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			p := Provider{ServiceChecks: test.checks}
+			actual := p.buildServiceChecks("svc")
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestProvider_ttlCheckIDs(t *testing.T) {
+	p := Provider{
+		ServiceChecks: []*ServiceCheck{
+			{HTTP: "http://127.0.0.1/ping"},
+			{TTL: ptypes.Duration(20 * time.Second)},
+			{TTL: ptypes.Duration(10 * time.Second)},
+		},
+	}
+
+	ids, pingInterval := p.ttlCheckIDs("svc")
+
+	assert.ElementsMatch(t, []string{"svc:check:1", "svc:check:2"}, ids)
+	assert.Equal(t, 5*time.Second, pingInterval)
+}